@@ -21,6 +21,7 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +32,7 @@ import (
 	"go.uber.org/zap"
 
 	dsync "github.com/pingcap/tidb-binlog/drainer/sync"
+	"github.com/pingcap/tidb-binlog/pkg/compress"
 	"github.com/pingcap/tidb-binlog/pkg/filter"
 	"github.com/pingcap/tidb-binlog/pkg/flags"
 	"github.com/pingcap/tidb-binlog/pkg/security"
@@ -53,7 +55,6 @@ const (
 var (
 	maxBinlogItemCount        int
 	defaultBinlogItemCount    = 16 << 12
-	supportedCompressors      = [...]string{"gzip"}
 	newZKFromConnectionString = zk.NewFromConnectionString
 )
 
@@ -73,11 +74,31 @@ type SyncerConfig struct {
 	DisableDispatch   bool               `toml:"disable-dispatch" json:"disable-dispatch"`
 	SafeMode          bool               `toml:"safe-mode" json:"safe-mode"`
 	DisableCausality  bool               `toml:"disable-detect" json:"disable-detect"`
+	// DispatchRules controls how binlog events are routed to Kafka
+	// partitions when DestDBType is "kafka"; see dsync.DispatchRules.
+	DispatchRules *dsync.DispatchRules `toml:"dispatch-rules" json:"dispatch-rules"`
+	// AllowCheckWithDispatch permits DestDBType "check" to run with
+	// DisableDispatch false. The check sink is not safe for concurrent
+	// use, so this is refused by default; see validate.
+	AllowCheckWithDispatch bool `toml:"allow-check-with-dispatch" json:"allow-check-with-dispatch"`
+
+	// IgnoreSchemasRegex/IgnoreTablesRegex/DoSchemasRegex/DoTablesRegex are
+	// regex equivalents of the exact-match fields above, each pattern
+	// anchored to match a full identifier. Precedence across all of these
+	// is: ignore wins over do, and exact match wins over regex match; see
+	// ShouldReplicateTable.
+	IgnoreSchemasRegex []string              `toml:"ignore-schemas-regex" json:"ignore-schemas-regex"`
+	IgnoreTablesRegex  []filter.TablePattern `toml:"ignore-table-regex" json:"ignore-table-regex"`
+	DoSchemasRegex     []string              `toml:"replicate-do-db-regex" json:"replicate-do-db-regex"`
+	DoTablesRegex      []filter.TablePattern `toml:"replicate-do-table-regex" json:"replicate-do-table-regex"`
+
+	compiledIgnoreSchemasRegex []*regexp.Regexp
+	compiledDoSchemasRegex     []*regexp.Regexp
 }
 
 // Config holds the configuration of drainer
 type Config struct {
-	*flag.FlagSet   `json:"-"`
+	*flag.FlagSet   `json:"-" toml:"-"`
 	LogLevel        string          `toml:"log-level" json:"log-level"`
 	NodeID          string          `toml:"node-id" json:"node-id"`
 	ListenAddr      string          `toml:"addr" json:"addr"`
@@ -91,19 +112,41 @@ type Config struct {
 	Security        security.Config `toml:"security" json:"security"`
 	SyncedCheckTime int             `toml:"synced-check-time" json:"synced-check-time"`
 	Compressor      string          `toml:"compressor" json:"compressor"`
+	CompressorLevel int             `toml:"compressor-level" json:"compressor-level"`
 	EtcdTimeout     time.Duration
 	MetricsAddr     string
 	MetricsInterval int
 	configFile      string
 	printVersion    bool
-	tls             *tls.Config
+	tls             *tls.Config `toml:"-"`
+
+	// kafkaTopicManager is set up by Start when DestDBType is "kafka"; it
+	// owns topic auto-creation and the background partition metadata
+	// refresh, and must be closed on drainer shutdown. It is deliberately
+	// not built by adjustConfig/Parse, which must stay usable offline (by
+	// e.g. the `check-config`/`dump-config` subcommands) without dialing a
+	// real broker.
+	kafkaTopicManager *dsync.KafkaTopicManager
+
+	// checkSink is set up by Start when DestDBType is "check"; it holds
+	// the live connection to CheckSourceDSN and must be closed on drainer
+	// shutdown.
+	checkSink *dsync.CheckSink
+
+	// kafkaSink is set up by Start when DestDBType is "kafka"; it owns the
+	// live sarama producer and must be closed on drainer shutdown.
+	kafkaSink *dsync.KafkaSink
+
+	// compressor is built by Start from Compressor/CompressorLevel and used
+	// to decode the payloads read off the pump->drainer gRPC stream.
+	compressor compress.Compressor
 }
 
 // NewConfig return an instance of configuration
 func NewConfig() *Config {
 	cfg := &Config{
 		EtcdTimeout: defaultEtcdTimeout,
-		SyncerCfg:   &SyncerConfig{},
+		SyncerCfg:   &SyncerConfig{DispatchRules: &dsync.DispatchRules{}},
 	}
 	cfg.FlagSet = flag.NewFlagSet("drainer", flag.ContinueOnError)
 	fs := cfg.FlagSet
@@ -124,11 +167,13 @@ func NewConfig() *Config {
 	fs.IntVar(&cfg.MetricsInterval, "metrics-interval", 15, "prometheus client push interval in second, set \"0\" to disable prometheus push")
 	fs.StringVar(&cfg.LogFile, "log-file", "", "log file path")
 	fs.Int64Var(&cfg.InitialCommitTS, "initial-commit-ts", 0, "if drainer donesn't have checkpoint, use initial commitTS to initial checkpoint")
-	fs.StringVar(&cfg.Compressor, "compressor", "", "use the specified compressor to compress payload between pump and drainer, only 'gzip' is supported now (default \"\", ie. compression disabled.)")
+	fs.StringVar(&cfg.Compressor, "compressor", "", "use the specified compressor to compress payload between pump and drainer: gzip, snappy, zstd, lz4, or none (default \"\", ie. compression disabled.)")
+	fs.IntVar(&cfg.CompressorLevel, "compressor-level", 0, "tuning level passed to the compressor, only meaningful for gzip and zstd (default 0, ie. the algorithm's own default)")
 	fs.IntVar(&cfg.SyncerCfg.TxnBatch, "txn-batch", 20, "number of binlog events in a transaction batch")
 	fs.StringVar(&cfg.SyncerCfg.IgnoreSchemas, "ignore-schemas", "INFORMATION_SCHEMA,PERFORMANCE_SCHEMA,mysql", "disable sync those schemas")
 	fs.IntVar(&cfg.SyncerCfg.WorkerCount, "c", 16, "parallel worker count")
-	fs.StringVar(&cfg.SyncerCfg.DestDBType, "dest-db-type", "mysql", "target db type: mysql or tidb or file or kafka; see syncer section in conf/drainer.toml")
+	fs.StringVar(&cfg.SyncerCfg.DestDBType, "dest-db-type", "mysql", "target db type: mysql, tidb, file, kafka, or check (verifies a downstream instead of writing to it); see syncer section in conf/drainer.toml")
+	fs.StringVar(&cfg.SyncerCfg.DispatchRules.Default, "dispatch-rule", dsync.DispatcherDefault, "default kafka partition dispatch rule when dest-db-type is kafka: default, ts, table, or index-value; per-table overrides can be set in the [[syncer.dispatch-rules.rules]] TOML section")
 	fs.BoolVar(&cfg.SyncerCfg.DisableDispatch, "disable-dispatch", false, "disable dispatching sqls that in one same binlog; if set true, work-count and txn-batch would be useless")
 	fs.BoolVar(&cfg.SyncerCfg.SafeMode, "safe-mode", false, "enable safe mode to make syncer reentrant")
 	fs.BoolVar(&cfg.SyncerCfg.DisableCausality, "disable-detect", false, "disable detect causality")
@@ -190,6 +235,10 @@ func (cfg *Config) Parse(args []string) error {
 		}
 	}
 
+	if err = cfg.SyncerCfg.compileFilterRegex(); err != nil {
+		return errors.Annotate(err, "invalid config")
+	}
+
 	cfg.tls, err = cfg.Security.ToTLSConfig()
 	if err != nil {
 		return errors.Errorf("tls config %+v error %v", cfg.Security, err)
@@ -207,6 +256,13 @@ func (c *SyncerConfig) adjustWorkCount() {
 	if c.DestDBType == "file" || c.DestDBType == "kafka" {
 		c.DisableDispatch = true
 		c.WorkerCount = 1
+	} else if c.DestDBType == "check" {
+		// unlike file/kafka, "check" doesn't force-disable dispatch: a
+		// user running it without disable-dispatch gets a clear error
+		// from validate instead of a silent forced change, since running
+		// concurrently against the check sink is a correctness bug, not
+		// just a performance knob.
+		c.WorkerCount = 1
 	} else if c.DisableDispatch {
 		c.WorkerCount = 1
 	}
@@ -222,6 +278,83 @@ func (c *SyncerConfig) adjustDoDBAndTable() {
 	}
 }
 
+// compileFilterRegex anchors and compiles the `*-regex` filter fields. It
+// must be called once after the TOML/flags are parsed and before
+// ShouldReplicateTable/ShouldReplicateSchema are used.
+func (c *SyncerConfig) compileFilterRegex() error {
+	var err error
+	if c.compiledIgnoreSchemasRegex, err = filter.CompileSchemaRegex(c.IgnoreSchemasRegex); err != nil {
+		return errors.Annotate(err, "ignore-schemas-regex")
+	}
+	if c.compiledDoSchemasRegex, err = filter.CompileSchemaRegex(c.DoSchemasRegex); err != nil {
+		return errors.Annotate(err, "replicate-do-db-regex")
+	}
+	for i := range c.IgnoreTablesRegex {
+		if err = c.IgnoreTablesRegex[i].Compile(); err != nil {
+			return errors.Annotate(err, "ignore-table-regex")
+		}
+	}
+	for i := range c.DoTablesRegex {
+		if err = c.DoTablesRegex[i].Compile(); err != nil {
+			return errors.Annotate(err, "replicate-do-table-regex")
+		}
+	}
+	return nil
+}
+
+// ShouldReplicateSchema reports whether schema passes the do/ignore filters,
+// consulting the same matchers the syncer's DML path uses so that DDL (e.g.
+// CREATE SCHEMA) is filtered consistently. Precedence: exact ignore > regex
+// ignore > exact do > regex do; if no do-list is configured at all (neither
+// exact nor regex), everything not ignored replicates.
+func (c *SyncerConfig) ShouldReplicateSchema(schema string) bool {
+	schema = strings.ToLower(schema)
+
+	if filter.MatchSchema(strings.Split(c.IgnoreSchemas, ","), schema) {
+		return false
+	}
+	if filter.MatchSchemaRegex(c.compiledIgnoreSchemasRegex, schema) {
+		return false
+	}
+
+	if len(c.DoDBs) == 0 && len(c.DoSchemasRegex) == 0 {
+		return true
+	}
+	if filter.MatchSchema(c.DoDBs, schema) {
+		return true
+	}
+	return filter.MatchSchemaRegex(c.compiledDoSchemasRegex, schema)
+}
+
+// ShouldReplicateTable reports whether schema.table passes the do/ignore
+// filters, consulting the same matchers the syncer's DML path uses so that a
+// CREATE TABLE matching an ignore pattern is skipped end-to-end.
+// Precedence: exact ignore > regex ignore > exact do > regex do, matching
+// ShouldReplicateSchema; if no do-list is configured at all (neither exact
+// nor regex), everything not ignored replicates.
+func (c *SyncerConfig) ShouldReplicateTable(schema, table string) bool {
+	schema, table = strings.ToLower(schema), strings.ToLower(table)
+
+	if !c.ShouldReplicateSchema(schema) {
+		return false
+	}
+
+	if filter.MatchTable(c.IgnoreTables, schema, table) {
+		return false
+	}
+	if filter.MatchTableRegex(c.IgnoreTablesRegex, schema, table) {
+		return false
+	}
+
+	if len(c.DoTables) == 0 && len(c.DoTablesRegex) == 0 {
+		return true
+	}
+	if filter.MatchTable(c.DoTables, schema, table) {
+		return true
+	}
+	return filter.MatchTableRegex(c.DoTablesRegex, schema, table)
+}
+
 func (cfg *Config) configFromFile(path string) error {
 	return util.StrictDecodeFile(path, "drainer", cfg)
 }
@@ -240,20 +373,29 @@ func (cfg *Config) validate() error {
 		return errors.Errorf("parse EtcdURLs error: %s, %v", cfg.EtcdURLs, err)
 	}
 
-	if cfg.Compressor != "" {
-		found := false
-		for _, c := range supportedCompressors {
-			if cfg.Compressor == c {
-				found = true
-				break
-			}
+	if cfg.SyncerCfg.DestDBType == "kafka" {
+		if cfg.SyncerCfg.DispatchRules == nil {
+			cfg.SyncerCfg.DispatchRules = &dsync.DispatchRules{}
 		}
-		if !found {
-			return errors.Errorf(
-				"Invalid compressor: %v, must be one of these: %v", cfg.Compressor, supportedCompressors)
+		if err := cfg.SyncerCfg.DispatchRules.Validate(); err != nil {
+			return errors.Annotate(err, "invalid dispatch-rules")
 		}
 	}
 
+	if cfg.SyncerCfg.DestDBType == "check" {
+		if cfg.SyncerCfg.To.CheckSourceDSN == "" {
+			return errors.New("check-source-dsn must be set when db-type is \"check\"")
+		}
+		if !cfg.SyncerCfg.DisableDispatch && !cfg.SyncerCfg.AllowCheckWithDispatch {
+			return errors.New("db-type \"check\" must run single-threaded; set disable-dispatch = true, or allow-check-with-dispatch = true to override")
+		}
+	}
+
+	if !compress.IsSupported(cfg.Compressor) {
+		return errors.Errorf(
+			"Invalid compressor: %v, must be one of these: %v", cfg.Compressor, compress.SupportedNames())
+	}
+
 	return nil
 }
 
@@ -348,6 +490,84 @@ func (cfg *Config) adjustConfig() error {
 	return nil
 }
 
+// Start dials the live downstream connections a resolved Config needs and
+// must only be called once Parse has succeeded and the caller actually
+// intends to replicate, e.g. from the `run` subcommand. It is kept separate
+// from adjustConfig/Parse/validate so that offline operations on a config
+// (`check-config`, `dump-config`, `validate-downstream`) never reach out to
+// a real broker or database.
+func (cfg *Config) Start() error {
+	compressor, err := compress.New(cfg.Compressor, cfg.CompressorLevel)
+	if err != nil {
+		return errors.Annotate(err, "create compressor")
+	}
+	cfg.compressor = compressor
+
+	if cfg.SyncerCfg.DestDBType == "kafka" {
+		topicManager, err := dsync.NewKafkaTopicManagerFromConfig(cfg.SyncerCfg.To, nil)
+		if err != nil {
+			return errors.Annotate(err, "create kafka topic manager")
+		}
+		topicManager.Start()
+		cfg.kafkaTopicManager = topicManager
+
+		kafkaSink, err := dsync.NewKafkaSinkFromConfig(cfg.SyncerCfg.To, cfg.SyncerCfg.DispatchRules, topicManager)
+		if err != nil {
+			return errors.Annotate(err, "create kafka sink")
+		}
+		cfg.kafkaSink = kafkaSink
+	}
+
+	if cfg.SyncerCfg.DestDBType == "check" {
+		checkSink, err := dsync.NewCheckSink(cfg.SyncerCfg.To)
+		if err != nil {
+			return errors.Annotate(err, "create check sink")
+		}
+		cfg.checkSink = checkSink
+	}
+
+	return nil
+}
+
+// CheckSink returns the check sink started by Start when DestDBType is
+// "check", or nil otherwise.
+func (cfg *Config) CheckSink() *dsync.CheckSink {
+	return cfg.checkSink
+}
+
+// KafkaSink returns the Kafka sink started by Start when DestDBType is
+// "kafka", or nil otherwise.
+func (cfg *Config) KafkaSink() *dsync.KafkaSink {
+	return cfg.kafkaSink
+}
+
+// Decompress decodes src with the compressor built by Start from
+// Compressor/CompressorLevel, e.g. the gRPC stream decoder unwrapping a
+// payload pump compressed before sending it. It returns src unchanged if no
+// compressor is configured.
+func (cfg *Config) Decompress(src []byte) ([]byte, error) {
+	if cfg.compressor == nil {
+		return src, nil
+	}
+	data, err := cfg.compressor.Decode(src)
+	return data, errors.Trace(err)
+}
+
+// Close releases resources started by Start, such as the Kafka topic
+// manager's background metadata refresh goroutine. It must be called once
+// on drainer shutdown.
+func (cfg *Config) Close() {
+	if cfg.kafkaTopicManager != nil {
+		cfg.kafkaTopicManager.Close()
+	}
+	if cfg.kafkaSink != nil {
+		cfg.kafkaSink.Close()
+	}
+	if cfg.checkSink != nil {
+		cfg.checkSink.Close()
+	}
+}
+
 func validateAddr(addr string) error {
 	urllis, err := url.Parse(addr)
 	if err != nil {
@@ -0,0 +1,51 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drainer
+
+import (
+	"testing"
+
+	"github.com/pingcap/tidb-binlog/pkg/filter"
+)
+
+// TestShouldReplicateTablePrecedence checks that ignore wins over do for a
+// table that matches an exact replicate-do-table entry and an
+// ignore-table-regex pattern at once, and that once a replicate-do-table list
+// is configured only the tables on it replicate.
+func TestShouldReplicateTablePrecedence(t *testing.T) {
+	cfg := &SyncerConfig{
+		DoTables:          []filter.TableName{{Schema: "test", Table: "t1"}},
+		IgnoreTablesRegex: []filter.TablePattern{{Schema: "test", Table: "t.*"}},
+	}
+	if err := cfg.compileFilterRegex(); err != nil {
+		t.Fatalf("compileFilterRegex: %v", err)
+	}
+
+	if cfg.ShouldReplicateTable("test", "t1") {
+		t.Fatal("ignore-table-regex should win over an exact replicate-do-table match")
+	}
+
+	cfg2 := &SyncerConfig{
+		DoTables: []filter.TableName{{Schema: "test", Table: "t1"}},
+	}
+	if err := cfg2.compileFilterRegex(); err != nil {
+		t.Fatalf("compileFilterRegex: %v", err)
+	}
+	if !cfg2.ShouldReplicateTable("test", "t1") {
+		t.Fatal("an exact replicate-do-table match should replicate when nothing ignores it")
+	}
+	if cfg2.ShouldReplicateTable("test", "t2") {
+		t.Fatal("a table absent from replicate-do-table should not replicate once a do-list is configured")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sync implements the downstream sinks (mysql/tidb, file, kafka, ...)
+// that a drainer syncer can replicate binlog events to.
+package sync
+
+import "time"
+
+// defaultMetadataRefreshInterval is how often the KafkaTopicManager refreshes
+// its cached partition counts when MetadataRefreshInterval is unset.
+const defaultMetadataRefreshInterval = 10 * time.Minute
+
+// DBConfig is the configuration of the downstream a syncer replicates to.
+type DBConfig struct {
+	Host     string `toml:"host" json:"host"`
+	User     string `toml:"user" json:"user"`
+	Password string `toml:"password" json:"password"`
+	Port     int    `toml:"port" json:"port"`
+
+	// BinlogFileDir is used when DestDBType is "file".
+	BinlogFileDir string `toml:"dir" json:"dir"`
+
+	// ZKAddrs is used to discover KafkaAddrs when DestDBType is "kafka".
+	ZKAddrs          string `toml:"zookeeper-addrs" json:"zookeeper-addrs"`
+	KafkaAddrs       string `toml:"kafka-addrs" json:"kafka-addrs"`
+	KafkaVersion     string `toml:"kafka-version" json:"kafka-version"`
+	KafkaMaxMessages int    `toml:"kafka-max-messages" json:"kafka-max-messages"`
+
+	// AutoCreateTopic, when true, has the KafkaTopicManager create the
+	// target topic(s) on drainer startup if they don't already exist.
+	AutoCreateTopic bool `toml:"auto-create-topic" json:"auto-create-topic"`
+	// PartitionNum is the partition count used when auto-creating a topic.
+	PartitionNum int32 `toml:"partition-num" json:"partition-num"`
+	// ReplicationFactor is the replication factor used when auto-creating
+	// a topic.
+	ReplicationFactor int16 `toml:"replication-factor" json:"replication-factor"`
+	// TopicNameStrategy picks how the target topic name is derived: one of
+	// "static" (TopicName as-is), "schema" (one topic per schema), or
+	// "schema-table" (one topic per table).
+	TopicNameStrategy string `toml:"topic-name-strategy" json:"topic-name-strategy"`
+	// TopicName is the static topic name, used as-is by the "static"
+	// strategy and as the prefix for the others.
+	TopicName string `toml:"topic-name" json:"topic-name"`
+	// MetadataRefreshInterval controls how often the KafkaTopicManager
+	// refreshes its cached partition counts in the background; it
+	// defaults to 10 minutes and is never consulted inline on the DML
+	// hot path.
+	MetadataRefreshInterval time.Duration `toml:"metadata-refresh-interval" json:"metadata-refresh-interval"`
+
+	// CheckSourceDSN is the real downstream the "check" sink verifies
+	// against; required when DestDBType is "check".
+	CheckSourceDSN string `toml:"check-source-dsn" json:"check-source-dsn"`
+	// CheckSampleRatio is the fraction (0, 1] of rows the "check" sink
+	// verifies when no per-table CheckSampleRatios rule matches; defaults
+	// to 1 (verify every row).
+	CheckSampleRatio float64 `toml:"check-sample-ratio" json:"check-sample-ratio"`
+	// CheckSampleRatios overrides CheckSampleRatio for tables matched by
+	// Matcher, using the same wildcard syntax as `replicate-do-table`.
+	CheckSampleRatios []CheckSampleRatioRule `toml:"check-sample-ratios" json:"check-sample-ratios"`
+}
+
+// CheckSampleRatioRule overrides the "check" sink's sampling ratio for
+// tables matched by Matcher.
+type CheckSampleRatioRule struct {
+	Matcher []string `toml:"matcher" json:"matcher"`
+	Ratio   float64  `toml:"ratio" json:"ratio"`
+}
+
+// TopicNameStrategy values accepted by DBConfig.TopicNameStrategy.
+const (
+	TopicNameStrategyStatic      = "static"
+	TopicNameStrategySchema      = "schema"
+	TopicNameStrategySchemaTable = "schema-table"
+)
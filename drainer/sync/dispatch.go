@@ -0,0 +1,144 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb-binlog/pkg/filter"
+)
+
+// Dispatcher names understood by DispatchRule.Dispatcher / --dispatch-rule.
+const (
+	// DispatcherDefault hashes on schema+table, the behavior drainer has
+	// always had: every row of a table lands on the same partition.
+	DispatcherDefault = "default"
+	// DispatcherTS hashes on the binlog's commit ts, spreading load evenly
+	// across partitions at the cost of per-key ordering.
+	DispatcherTS = "ts"
+	// DispatcherTable hashes on schema.table, keeping a whole table on a
+	// single partition (an alias of "default", kept as an explicit name
+	// since "default" may grow other hashing strategies later).
+	DispatcherTable = "table"
+	// DispatcherIndexValue hashes on the primary/unique key values of the
+	// row together with schema.table, so that every update to the same row
+	// of a table lands on the same partition.
+	DispatcherIndexValue = "index-value"
+)
+
+// IsValidDispatcher reports whether name is one of the supported dispatcher
+// modes.
+func IsValidDispatcher(name string) bool {
+	switch name {
+	case DispatcherDefault, DispatcherTS, DispatcherTable, DispatcherIndexValue:
+		return true
+	default:
+		return false
+	}
+}
+
+// DispatchRule maps tables matched by Matcher to the Dispatcher that decides
+// which Kafka partition their events go to.
+type DispatchRule struct {
+	Matcher    []string `toml:"matcher" json:"matcher"`
+	Dispatcher string   `toml:"dispatcher" json:"dispatcher"`
+
+	tables []filter.TableName
+}
+
+// DispatchRules resolves the dispatcher to use for a given schema.table,
+// consulting per-matcher rules before falling back to Default.
+type DispatchRules struct {
+	Default string         `toml:"default-dispatcher" json:"default-dispatcher"`
+	Rules   []DispatchRule `toml:"rules" json:"rules"`
+}
+
+// Validate compiles the matcher patterns and rejects unknown dispatcher
+// names. It must be called once after the rules are parsed from TOML/flags.
+func (d *DispatchRules) Validate() error {
+	if d.Default == "" {
+		d.Default = DispatcherDefault
+	}
+	if !IsValidDispatcher(d.Default) {
+		return errors.Errorf("unknown dispatcher %q for default-dispatcher", d.Default)
+	}
+
+	for i := range d.Rules {
+		rule := &d.Rules[i]
+		if !IsValidDispatcher(rule.Dispatcher) {
+			return errors.Errorf("unknown dispatcher %q in dispatch rule matching %v", rule.Dispatcher, rule.Matcher)
+		}
+		rule.tables = rule.tables[:0]
+		for _, m := range rule.Matcher {
+			tbl, err := filter.ParseTableName(m)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			rule.tables = append(rule.tables, tbl)
+		}
+	}
+
+	return nil
+}
+
+// DispatcherFor returns the dispatcher configured for schema.table, the
+// first matching rule wins, falling back to Default when nothing matches.
+func (d *DispatchRules) DispatcherFor(schema, table string) string {
+	for _, rule := range d.Rules {
+		if filter.MatchTable(rule.tables, schema, table) {
+			return rule.Dispatcher
+		}
+	}
+	return d.Default
+}
+
+// PartitionKey computes the partition a binlog event for schema.table should
+// be routed to, given the dispatcher resolved for it. indexValues holds the
+// primary/unique key column values in column order and is required by
+// DispatcherIndexValue; callers must reject that dispatcher ahead of time for
+// tables with no usable unique key (see RequiresIndexValue).
+func PartitionKey(dispatcher, schema, table string, commitTS int64, indexValues []string) (string, error) {
+	switch dispatcher {
+	case DispatcherDefault, DispatcherTable:
+		return schema + "." + table, nil
+	case DispatcherTS:
+		return fmt.Sprintf("%d", commitTS), nil
+	case DispatcherIndexValue:
+		if len(indexValues) == 0 {
+			return "", errors.Errorf("dispatcher %q requires a primary/unique key value for %s.%s", dispatcher, schema, table)
+		}
+		return schema + "." + table + "." + strings.Join(indexValues, ","), nil
+	default:
+		return "", errors.Errorf("unknown dispatcher %q", dispatcher)
+	}
+}
+
+// RequiresIndexValue reports whether dispatcher needs a usable unique key to
+// compute a partition key, so callers can validate at runtime that a matched
+// table actually has one.
+func RequiresIndexValue(dispatcher string) bool {
+	return dispatcher == DispatcherIndexValue
+}
+
+// HashPartition maps a partition key to one of partitionNum partitions using
+// the same CRC32 hash the default dispatcher has always used.
+func HashPartition(key string, partitionNum int32) int32 {
+	if partitionNum <= 0 {
+		return 0
+	}
+	return int32(crc32.ChecksumIEEE([]byte(key)) % uint32(partitionNum))
+}
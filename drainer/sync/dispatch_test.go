@@ -0,0 +1,71 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import "testing"
+
+func TestDispatchRulesDispatcherFor(t *testing.T) {
+	rules := &DispatchRules{
+		Default: DispatcherDefault,
+		Rules: []DispatchRule{
+			{Matcher: []string{"test.t1"}, Dispatcher: DispatcherIndexValue},
+		},
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	if got := rules.DispatcherFor("test", "t1"); got != DispatcherIndexValue {
+		t.Errorf("DispatcherFor(test.t1) = %q, want %q", got, DispatcherIndexValue)
+	}
+	if got := rules.DispatcherFor("test", "t2"); got != DispatcherDefault {
+		t.Errorf("DispatcherFor(test.t2) = %q, want default %q", got, DispatcherDefault)
+	}
+}
+
+func TestDispatchRulesValidateRejectsUnknownDispatcher(t *testing.T) {
+	rules := &DispatchRules{Rules: []DispatchRule{{Matcher: []string{"test.t1"}, Dispatcher: "bogus"}}}
+	if err := rules.Validate(); err == nil {
+		t.Fatal("expected an error for an unknown dispatcher name")
+	}
+}
+
+func TestPartitionKeyIndexValueRequiresKey(t *testing.T) {
+	if _, err := PartitionKey(DispatcherIndexValue, "test", "t1", 0, nil); err == nil {
+		t.Fatal("expected an error when indexValues is empty for the index-value dispatcher")
+	}
+
+	key, err := PartitionKey(DispatcherIndexValue, "test", "t1", 0, []string{"1"})
+	if err != nil {
+		t.Fatalf("PartitionKey: %v", err)
+	}
+	if want := "test.t1.1"; key != want {
+		t.Errorf("PartitionKey = %q, want %q", key, want)
+	}
+}
+
+func TestHashPartitionIsStableAndInRange(t *testing.T) {
+	const partitionNum = int32(4)
+	p1 := HashPartition("test.t1", partitionNum)
+	p2 := HashPartition("test.t1", partitionNum)
+	if p1 != p2 {
+		t.Fatalf("HashPartition is not deterministic: %d != %d", p1, p2)
+	}
+	if p1 < 0 || p1 >= partitionNum {
+		t.Fatalf("HashPartition returned %d, want [0, %d)", p1, partitionNum)
+	}
+	if got := HashPartition("test.t1", 0); got != 0 {
+		t.Errorf("HashPartition with partitionNum=0 = %d, want 0", got)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/errors"
+)
+
+// KafkaSink replicates binlog events to Kafka, routing each event to a
+// partition chosen by DispatchRules. It consults topicManager for the
+// current partition count of the target topic on every send, rather than
+// assuming the count observed at initial connect, so that partitions added
+// to a topic after drainer started are used without a restart.
+type KafkaSink struct {
+	rules        *DispatchRules
+	topicManager *KafkaTopicManager
+	client       sarama.SyncProducer
+}
+
+// NewKafkaSink creates a KafkaSink that publishes through client, routing
+// events according to rules and resolving topics/partition counts via
+// topicManager. rules must already have been validated. client must have
+// been configured with sarama.Config.Producer.Partitioner set to
+// sarama.NewManualPartitioner, or the partition Send computes is silently
+// discarded in favor of sarama's default hash partitioner;
+// NewKafkaSinkFromConfig takes care of this for callers that don't already
+// have a producer.
+func NewKafkaSink(topicManager *KafkaTopicManager, rules *DispatchRules, client sarama.SyncProducer) *KafkaSink {
+	if rules == nil {
+		rules = &DispatchRules{Default: DispatcherDefault}
+	}
+	return &KafkaSink{rules: rules, topicManager: topicManager, client: client}
+}
+
+// NewKafkaSinkFromConfig dials a sarama SyncProducer against cfg.KafkaAddrs,
+// forcing the manual partitioner Send's partition routing depends on, and
+// wraps it in a KafkaSink. Callers are responsible for calling Close on
+// drainer shutdown.
+func NewKafkaSinkFromConfig(cfg *DBConfig, rules *DispatchRules, topicManager *KafkaTopicManager) (*KafkaSink, error) {
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Version = sarama.V0_8_2_0
+	saramaCfg.Producer.Partitioner = sarama.NewManualPartitioner
+	saramaCfg.Producer.Return.Successes = true
+
+	client, err := sarama.NewSyncProducer(strings.Split(cfg.KafkaAddrs, ","), saramaCfg)
+	if err != nil {
+		return nil, errors.Annotate(err, "create kafka sync producer")
+	}
+	return NewKafkaSink(topicManager, rules, client), nil
+}
+
+// Close closes the underlying producer.
+func (s *KafkaSink) Close() error {
+	return s.client.Close()
+}
+
+// Send publishes a single encoded binlog event for schema.table to Kafka,
+// picking the topic and partition via the configured topic-name strategy and
+// dispatch rule. indexValues is the row's primary/unique key values in
+// column order, used by the "index-value" dispatcher; it may be nil for
+// dispatchers that don't need it.
+func (s *KafkaSink) Send(schema, table string, commitTS int64, indexValues []string, data []byte) error {
+	dispatcher := s.rules.DispatcherFor(schema, table)
+	if RequiresIndexValue(dispatcher) && len(indexValues) == 0 {
+		return errors.Errorf("table %s.%s has no usable unique key, cannot dispatch by %q", schema, table, dispatcher)
+	}
+
+	key, err := PartitionKey(dispatcher, schema, table, commitTS, indexValues)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	topic := s.topicManager.TopicName(schema, table)
+	partitionNum, ok := s.topicManager.PartitionNum(topic)
+	if !ok {
+		// first time we see this topic: create it if needed and seed the
+		// partition count cache. Later sends hit the cache above and rely
+		// on the background refresh goroutine to pick up new partitions.
+		if err := s.topicManager.EnsureTopic(topic); err != nil {
+			return errors.Trace(err)
+		}
+		partitionNum, _ = s.topicManager.PartitionNum(topic)
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic:     topic,
+		Partition: HashPartition(key, partitionNum),
+		Value:     sarama.ByteEncoder(data),
+	}
+
+	_, _, err = s.client.SendMessage(msg)
+	return errors.Trace(err)
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// KafkaTopicManager creates the Kafka topics the syncer writes to if they
+// don't already exist, and keeps a cache of each topic's current partition
+// count fresh so the producer/dispatcher picks up newly added partitions
+// without a drainer restart.
+type KafkaTopicManager struct {
+	cfg   *DBConfig
+	admin sarama.ClusterAdmin
+
+	partitionNum sync.Map // topic(string) -> partition count (int32)
+
+	refreshInterval time.Duration
+	cancel          func()
+	wg              sync.WaitGroup
+}
+
+// NewKafkaTopicManagerFromConfig dials a sarama ClusterAdmin against
+// cfg.KafkaAddrs and wraps it in a KafkaTopicManager. Callers are
+// responsible for calling Start to begin the background metadata refresh
+// and Close on drainer shutdown.
+func NewKafkaTopicManagerFromConfig(cfg *DBConfig, saramaCfg *sarama.Config) (*KafkaTopicManager, error) {
+	if saramaCfg == nil {
+		saramaCfg = sarama.NewConfig()
+		saramaCfg.Version = sarama.V0_8_2_0
+	}
+	admin, err := sarama.NewClusterAdmin(strings.Split(cfg.KafkaAddrs, ","), saramaCfg)
+	if err != nil {
+		return nil, errors.Annotate(err, "create kafka cluster admin")
+	}
+	return NewKafkaTopicManager(cfg, admin), nil
+}
+
+// NewKafkaTopicManager builds a KafkaTopicManager backed by admin, using the
+// AutoCreateTopic/PartitionNum/ReplicationFactor/MetadataRefreshInterval
+// settings from cfg. It does not start the background refresh goroutine;
+// call Start for that.
+func NewKafkaTopicManager(cfg *DBConfig, admin sarama.ClusterAdmin) *KafkaTopicManager {
+	interval := cfg.MetadataRefreshInterval
+	if interval <= 0 {
+		interval = defaultMetadataRefreshInterval
+	}
+	return &KafkaTopicManager{cfg: cfg, admin: admin, refreshInterval: interval}
+}
+
+// EnsureTopic makes sure topic exists, creating it with the configured
+// partition count and replication factor when AutoCreateTopic is set and the
+// topic is missing, and populates the partition count cache for it either way.
+func (m *KafkaTopicManager) EnsureTopic(topic string) error {
+	topics, err := m.admin.ListTopics()
+	if err != nil {
+		return errors.Annotate(err, "list kafka topics")
+	}
+
+	detail, ok := topics[topic]
+	if !ok {
+		if !m.cfg.AutoCreateTopic {
+			return errors.Errorf("kafka topic %q does not exist and auto-create-topic is disabled", topic)
+		}
+
+		partitionNum := m.cfg.PartitionNum
+		if partitionNum <= 0 {
+			partitionNum = 1
+		}
+		replicationFactor := m.cfg.ReplicationFactor
+		if replicationFactor <= 0 {
+			replicationFactor = 1
+		}
+
+		log.Info("creating kafka topic",
+			zap.String("topic", topic),
+			zap.Int32("partition-num", partitionNum),
+			zap.Int16("replication-factor", replicationFactor))
+
+		err = m.admin.CreateTopic(topic, &sarama.TopicDetail{
+			NumPartitions:     partitionNum,
+			ReplicationFactor: replicationFactor,
+		}, false)
+		// another drainer/producer may have created it concurrently
+		if err != nil && !strings.Contains(err.Error(), sarama.ErrTopicAlreadyExists.Error()) {
+			return errors.Annotatef(err, "create kafka topic %q", topic)
+		}
+
+		m.partitionNum.Store(topic, partitionNum)
+		return nil
+	}
+
+	m.partitionNum.Store(topic, detail.NumPartitions)
+	return nil
+}
+
+// PartitionNum returns the cached partition count for topic, refreshed at
+// most once per MetadataRefreshInterval by the background goroutine started
+// with Start. It returns false if the topic hasn't been seen by EnsureTopic
+// or a refresh yet.
+func (m *KafkaTopicManager) PartitionNum(topic string) (int32, bool) {
+	v, ok := m.partitionNum.Load(topic)
+	if !ok {
+		return 0, false
+	}
+	return v.(int32), true
+}
+
+// TopicName derives the target topic name for schema.table according to the
+// configured TopicNameStrategy.
+func (m *KafkaTopicManager) TopicName(schema, table string) string {
+	switch m.cfg.TopicNameStrategy {
+	case TopicNameStrategySchema:
+		return m.cfg.TopicName + "_" + schema
+	case TopicNameStrategySchemaTable:
+		return m.cfg.TopicName + "_" + schema + "_" + table
+	default:
+		return m.cfg.TopicName
+	}
+}
+
+// Start launches the background goroutine that refreshes the cached
+// partition counts every MetadataRefreshInterval. It is intentionally never
+// invoked inline on the DML path, so that many tables mapping to their own
+// topic doesn't hammer the broker with metadata requests. Close stops it.
+func (m *KafkaTopicManager) Start() {
+	ctx, cancel := newCancelableTicker()
+	m.cancel = cancel
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.refresh()
+			case <-ctx:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background refresh goroutine started by Start and blocks
+// until it has exited. It is safe to call even if Start was never called.
+func (m *KafkaTopicManager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+func (m *KafkaTopicManager) refresh() {
+	partitions, err := m.admin.ListTopics()
+	if err != nil {
+		log.Warn("refresh kafka topic metadata failed", zap.Error(err))
+		return
+	}
+
+	m.partitionNum.Range(func(k, _ interface{}) bool {
+		topic := k.(string)
+		if detail, ok := partitions[topic]; ok {
+			m.partitionNum.Store(topic, detail.NumPartitions)
+		}
+		return true
+	})
+}
+
+// newCancelableTicker returns a channel that's closed when the returned
+// cancel func is called, for use as a goroutine's stop signal.
+func newCancelableTicker() (<-chan struct{}, func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	return done, func() { once.Do(func() { close(done) }) }
+}
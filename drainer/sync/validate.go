@@ -0,0 +1,81 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/pingcap/errors"
+)
+
+// ValidateDownstream opens a trial connection to the destination described
+// by destDBType/to and reports what would actually be used, without
+// replicating anything. It is meant for the `validate-downstream` operator
+// subcommand, not the hot path.
+func ValidateDownstream(destDBType string, to *DBConfig) error {
+	switch destDBType {
+	case "mysql", "tidb":
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", to.User, to.Password, to.Host, to.Port)
+		db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			return errors.Annotate(err, "open downstream")
+		}
+		defer db.Close()
+		if err := db.Ping(); err != nil {
+			return errors.Annotatef(err, "ping %s:%d as %s", to.Host, to.Port, to.User)
+		}
+		fmt.Printf("ok: would replicate to %s %s:%d as %s\n", destDBType, to.Host, to.Port, to.User)
+		return nil
+
+	case "kafka":
+		client, err := sarama.NewClient(strings.Split(to.KafkaAddrs, ","), nil)
+		if err != nil {
+			return errors.Annotatef(err, "dial kafka at %s", to.KafkaAddrs)
+		}
+		defer client.Close()
+		fmt.Printf("ok: would replicate to kafka %s, topic strategy %q, auto-create-topic=%v\n",
+			to.KafkaAddrs, to.TopicNameStrategy, to.AutoCreateTopic)
+		return nil
+
+	case "check":
+		db, err := sql.Open("mysql", to.CheckSourceDSN)
+		if err != nil {
+			return errors.Annotate(err, "open check-source-dsn")
+		}
+		defer db.Close()
+		if err := db.Ping(); err != nil {
+			return errors.Annotate(err, "ping check-source-dsn")
+		}
+		fmt.Printf("ok: would check against %s\n", to.CheckSourceDSN)
+		return nil
+
+	case "file", "pb":
+		info, err := os.Stat(to.BinlogFileDir)
+		if err != nil {
+			return errors.Annotatef(err, "stat binlog file directory %q", to.BinlogFileDir)
+		}
+		if !info.IsDir() {
+			return errors.Errorf("%q is not a directory", to.BinlogFileDir)
+		}
+		fmt.Printf("ok: would write binlog files to %s\n", to.BinlogFileDir)
+		return nil
+
+	default:
+		return errors.Errorf("unknown dest-db-type %q", destDBType)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package compress implements the pluggable payload compressors used
+// between pump and drainer, selected by the `--compressor` flag /
+// `compressor` TOML key.
+package compress
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+// None is the explicit alias for "no compression", equivalent to leaving
+// `compressor` unset.
+const None = "none"
+
+// Compressor encodes and decodes payloads with a single algorithm.
+type Compressor interface {
+	// Name is the registry key this Compressor was constructed for, e.g. "gzip".
+	Name() string
+	Encode(src []byte) ([]byte, error)
+	Decode(src []byte) ([]byte, error)
+}
+
+// Factory builds a Compressor, applying level as the algorithm's tuning
+// knob (compression level) when the algorithm supports one; level <= 0
+// means "use the algorithm's default".
+type Factory func(level int) (Compressor, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register adds a compressor implementation to the registry under name. It
+// is meant to be called from the init() of each algorithm's file and panics
+// on a duplicate name, which only happens on programmer error.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("compress: Register called twice for %q", name))
+	}
+	factories[name] = factory
+}
+
+// New builds the Compressor registered under name with the given tuning
+// level. name == "" or name == None always succeeds and returns a no-op
+// Compressor.
+func New(name string, level int) (Compressor, error) {
+	if name == "" || name == None {
+		return noopCompressor{}, nil
+	}
+
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown compressor %q, must be one of: %v", name, SupportedNames())
+	}
+	return factory(level)
+}
+
+// IsSupported reports whether name is "none"/"" or a registered compressor.
+func IsSupported(name string) bool {
+	if name == "" || name == None {
+		return true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := factories[name]
+	return ok
+}
+
+// SupportedNames returns the sorted list of registered compressor names,
+// plus "none".
+func SupportedNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories)+1)
+	names = append(names, None)
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type noopCompressor struct{}
+
+func (noopCompressor) Name() string                      { return None }
+func (noopCompressor) Encode(src []byte) ([]byte, error) { return src, nil }
+func (noopCompressor) Decode(src []byte) ([]byte, error) { return src, nil }
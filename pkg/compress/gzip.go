@@ -0,0 +1,70 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+
+	"github.com/pingcap/errors"
+)
+
+func init() {
+	Register("gzip", newGzipCompressor)
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func newGzipCompressor(level int) (Compressor, error) {
+	if level <= 0 {
+		level = gzip.DefaultCompression
+	}
+	if level > gzip.BestCompression {
+		return nil, errors.Errorf("gzip: compressor-level must be <= %d", gzip.BestCompression)
+	}
+	return gzipCompressor{level: level}, nil
+}
+
+func (gzipCompressor) Name() string { return "gzip" }
+
+func (c gzipCompressor) Encode(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decode(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return data, nil
+}
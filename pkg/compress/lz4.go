@@ -0,0 +1,57 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/pierrec/lz4"
+	"github.com/pingcap/errors"
+)
+
+func init() {
+	Register("lz4", newLZ4Compressor)
+}
+
+type lz4Compressor struct{}
+
+// newLZ4Compressor ignores level: payloads use the lz4 frame format with the
+// writer's default settings.
+func newLZ4Compressor(int) (Compressor, error) {
+	return lz4Compressor{}, nil
+}
+
+func (lz4Compressor) Name() string { return "lz4" }
+
+func (lz4Compressor) Encode(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := lz4.NewWriter(&buf)
+	if _, err := w.Write(src); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (lz4Compressor) Decode(src []byte) ([]byte, error) {
+	r := lz4.NewReader(bytes.NewReader(src))
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return data, nil
+}
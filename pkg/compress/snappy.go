@@ -0,0 +1,44 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"github.com/golang/snappy"
+	"github.com/pingcap/errors"
+)
+
+func init() {
+	Register("snappy", newSnappyCompressor)
+}
+
+type snappyCompressor struct{}
+
+// newSnappyCompressor ignores level: snappy has no tunable compression level.
+func newSnappyCompressor(int) (Compressor, error) {
+	return snappyCompressor{}, nil
+}
+
+func (snappyCompressor) Name() string { return "snappy" }
+
+func (snappyCompressor) Encode(src []byte) ([]byte, error) {
+	return snappy.Encode(nil, src), nil
+}
+
+func (snappyCompressor) Decode(src []byte) ([]byte, error) {
+	data, err := snappy.Decode(nil, src)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return data, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compress
+
+import (
+	"github.com/klauspost/compress/zstd"
+	"github.com/pingcap/errors"
+)
+
+func init() {
+	Register("zstd", newZstdCompressor)
+}
+
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func newZstdCompressor(level int) (Compressor, error) {
+	encLevel := zstd.SpeedDefault
+	if level > 0 {
+		encLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encLevel))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &zstdCompressor{encoder: enc, decoder: dec}, nil
+}
+
+func (*zstdCompressor) Name() string { return "zstd" }
+
+func (c *zstdCompressor) Encode(src []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(src, nil), nil
+}
+
+func (c *zstdCompressor) Decode(src []byte) ([]byte, error) {
+	data, err := c.decoder.DecodeAll(src, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return data, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filter implements matching of schema/table names against the
+// do/ignore lists configured for the syncer.
+package filter
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// TableName represents an exact (possibly wildcarded with "*") schema.table pair
+// as written in `replicate-do-table` / `ignore-table` TOML entries.
+type TableName struct {
+	Schema string `toml:"db-name" json:"db-name"`
+	Table  string `toml:"tbl-name" json:"tbl-name"`
+}
+
+// MatchTable reports whether schema.table matches any of the patterns. Each
+// component of a pattern may be "*" to match any schema or table name.
+func MatchTable(patterns []TableName, schema, table string) bool {
+	for _, p := range patterns {
+		if matchOne(p.Schema, schema) && matchOne(p.Table, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchSchema reports whether schema matches any of the given names, where a
+// name of "*" matches every schema.
+func MatchSchema(patterns []string, schema string) bool {
+	for _, p := range patterns {
+		if matchOne(p, schema) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTableName parses a "schema.table" pattern such as "db.*" or "*.*" into
+// a TableName, for use by configs that accept the same wildcard syntax as
+// `replicate-do-table`.
+func ParseTableName(pattern string) (TableName, error) {
+	parts := strings.SplitN(pattern, ".", 2)
+	if len(parts) != 2 {
+		return TableName{}, errors.Errorf("invalid table pattern %q, expect the form `schema.table`", pattern)
+	}
+	return TableName{Schema: parts[0], Table: parts[1]}, nil
+}
+
+func matchOne(pattern, name string) bool {
+	if pattern == "*" {
+		return true
+	}
+	return strings.EqualFold(pattern, name)
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"regexp"
+
+	"github.com/pingcap/errors"
+)
+
+// TablePattern is a schema/table matcher expressed as regular expressions,
+// each anchored to match a full identifier, used by the `*-regex` syncer
+// config fields. The zero value does not match anything until Compile is
+// called.
+type TablePattern struct {
+	Schema string `toml:"db-name" json:"db-name"`
+	Table  string `toml:"tbl-name" json:"tbl-name"`
+
+	schemaRe *regexp.Regexp
+	tableRe  *regexp.Regexp
+}
+
+// Compile anchors and compiles the Schema/Table regular expressions. It must
+// be called once, typically at Config.Parse time, before Match is used.
+func (p *TablePattern) Compile() error {
+	var err error
+	if p.schemaRe, err = compileAnchored(p.Schema); err != nil {
+		return errors.Annotatef(err, "invalid schema pattern %q", p.Schema)
+	}
+	if p.tableRe, err = compileAnchored(p.Table); err != nil {
+		return errors.Annotatef(err, "invalid table pattern %q", p.Table)
+	}
+	return nil
+}
+
+// Match reports whether schema.table matches this pattern.
+func (p *TablePattern) Match(schema, table string) bool {
+	return p.schemaRe != nil && p.tableRe != nil && p.schemaRe.MatchString(schema) && p.tableRe.MatchString(table)
+}
+
+// MatchTableRegex reports whether schema.table matches any of patterns,
+// which must already have been compiled with Compile.
+func MatchTableRegex(patterns []TablePattern, schema, table string) bool {
+	for i := range patterns {
+		if patterns[i].Match(schema, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompileSchemaRegex anchors and compiles each entry of patterns, for later
+// use with MatchSchemaRegex.
+func CompileSchemaRegex(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := compileAnchored(p)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid schema pattern %q", p)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// MatchSchemaRegex reports whether schema matches any of the compiled
+// patterns returned by CompileSchemaRegex.
+func MatchSchemaRegex(patterns []*regexp.Regexp, schema string) bool {
+	for _, re := range patterns {
+		if re.MatchString(schema) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileAnchored(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}